@@ -1,19 +1,26 @@
 // Package btrfs provides utilities for interacting with BTRFS snapshots,
-// particularly for reading and parsing snapshot metadata stored in XML format.
+// including pluggable support for the Snapper, Timeshift, and plain-directory
+// snapshot-metadata layouts (see provider.go).
 package btrfs
 
 import (
-	"encoding/xml"
+	"context"
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/xeyossr/btrfs-rollback/internal/config"
+	"github.com/xeyossr/btrfs-rollback/internal/logging"
+	"github.com/xeyossr/btrfs-rollback/internal/store"
 )
 
+// topLevelSubvolID is btrfs's well-known subvolid for a filesystem's
+// top-level subvolume, used as a safe place to point the default subvolume
+// at while the real default is being deleted and recreated.
+const topLevelSubvolID = "5"
+
 // Global dryRun flag controlled via cmd package
 var dryRun bool
 
@@ -22,12 +29,18 @@ func SetDryRun(enabled bool) {
 	dryRun = enabled
 }
 
-// Snapshot represents the metadata of a BTRFS snapshot.
+// Snapshot represents the metadata of a BTRFS snapshot, as produced by any
+// SnapshotProvider. DescriptionStr, Tags, Comments, and UUID are populated
+// on a best-effort basis: not every provider has all of them.
 type Snapshot struct {
 	ID             string `xml:"num"`
 	Method         string `xml:"type"`
 	Date           string `xml:"date"`
 	DescriptionStr string `xml:"description"`
+
+	Tags     string `xml:"-"`
+	Comments string `xml:"-"`
+	UUID     string `xml:"-"`
 }
 
 // realIsBtrfsSubvolume returns true if the path is a Btrfs subvolume.
@@ -38,78 +51,21 @@ func realIsBtrfsSubvolume(path string) bool {
 	return err == nil
 }
 
-// listSubvolumes returns all subvolumes inside the snapshot directory defined in cfg.
-func listSubvolumes(cfg config.Config) ([]string, error) {
-	var subvolumes []string
-	snapshotDir := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots)
-
-	entries, err := os.ReadDir(snapshotDir)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
-		fullPath := filepath.Join(snapshotDir, entry.Name(), "snapshot")
-		if entry.IsDir() && realIsBtrfsSubvolume(fullPath) {
-			subvolumes = append(subvolumes, fullPath)
-		}
-	}
-
-	return subvolumes, nil
-}
-
-// ReadSnapshotInfo reads the info.xml file inside snapshotPath and returns a Snapshot
-func ReadSnapshotInfo(cfg config.Config, snapshotPath string) (Snapshot, error) {
-	infoXMLPath := filepath.Join(filepath.Dir(snapshotPath), "info.xml")
-
-	xmlData, err := os.ReadFile(infoXMLPath)
-	if err != nil {
-		return Snapshot{}, fmt.Errorf("could not read XML file: %v", err)
-	}
-
-	var snapshot Snapshot
-	err = xml.Unmarshal(xmlData, &snapshot)
-	if err != nil {
-		return Snapshot{}, fmt.Errorf("could not unmarshal XML: %v", err)
-	}
-
-	return snapshot, nil
-}
-
-// GetSnapshots returns all snapshots found in the snapshot subvolume directory
-// Sorts them by numeric ID in ascending order
+// GetSnapshots returns all snapshots found by cfg's configured
+// SnapshotProvider (see provider.go), sorted by ID in descending order.
 func GetSnapshots(cfg config.Config) ([]Snapshot, error) {
-	subvolPaths, err := listSubvolumes(cfg)
+	provider, err := GetProvider(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list snapshot subvolumes: %v", err)
-	}
-
-	var snapshots []Snapshot
-	for _, subvol := range subvolPaths {
-		snapshot, err := ReadSnapshotInfo(cfg, subvol)
-		if err != nil {
-			return nil, fmt.Errorf("could not read snapshot info for %s: %v", subvol, err)
-		}
-		snapshots = append(snapshots, snapshot)
+		return nil, withCode(CodeProviderNotFound, err)
 	}
 
-	sort.Slice(snapshots, func(i, j int) bool {
-		idI, errI := strconv.Atoi(snapshots[i].ID)
-		idJ, errJ := strconv.Atoi(snapshots[j].ID)
-		if errI != nil {
-			return false
-		}
-		if errJ != nil {
-			return true
-		}
-		return idI > idJ
-	})
-
-	return snapshots, nil
+	return provider.List(cfg)
 }
 
 // MountSubvol performs the mount operation for the BTRFS subvolume if not already mounted
-func MountSubvol(cfg config.Config) error {
+func MountSubvol(ctx context.Context, cfg config.Config) error {
+	logger := logging.FromContext(ctx)
+
 	// Check if the mountpoint is already mounted by inspecting the mount status
 	cmd := exec.Command("mount", "--grep", cfg.Mountpoint)
 	output, err := cmd.CombinedOutput()
@@ -119,73 +75,408 @@ func MountSubvol(cfg config.Config) error {
 			// Mount the btrfs subvolume if it's not mounted
 			cmd := exec.Command("mount", "-o", "subvolid=5", *cfg.Dev, cfg.Mountpoint)
 			if dryRun {
-				fmt.Printf("[dry-run] Would execute: %v\n", cmd.Args)
+				logger.Info("mount", "step", "mount", "device", *cfg.Dev, "mountpoint", cfg.Mountpoint, "dry_run", true)
 			}
 			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("failed to mount %s: %v", *cfg.Dev, err)
+				return withCode(CodeMountFailed, fmt.Errorf("failed to mount %s: %v", *cfg.Dev, err))
 			}
 		} else {
-			return fmt.Errorf("device not specified, unable to mount %s", cfg.Mountpoint)
+			return withCode(CodeMountFailed, fmt.Errorf("device not specified, unable to mount %s", cfg.Mountpoint))
 		}
 	} else {
-		fmt.Println("Mountpoint is already mounted.")
+		logger.Info("mountpoint already mounted", "mountpoint", cfg.Mountpoint)
 	}
 	return nil
 }
 
-// Rollback performs a rollback by:
-// 1. Renaming the current SubvolMain to SubvolMain.old
-// 2. Creating a snapshot from the specified snapshot subvolume into SubvolMain
-// 3. Setting the default subvolume to SubvolMain
-// Honors dry-run mode by printing commands instead of executing them
-func Rollback(cfg config.Config, snapshotID string) error {
-	subvolMainPath := filepath.Join(cfg.Mountpoint, cfg.SubvolMain)
-	oldSubvolMainPath := subvolMainPath + ".old"
-	snapshotPath := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots, snapshotID, "snapshot")
+// swapMain moves cfg.SubvolMain out of the way into a uniquely named
+// "<SubvolMain>.old-<unix-timestamp>" subvolume and replaces it with a fresh
+// writable snapshot taken from snapshotPath. Unlike the previous single-shot
+// ".old" suffix, the preserved subvolume is never deleted here. Returns the
+// path of the preserved old subvolume. Used by Confirm, which leaves that
+// subvolume in place and does not record it in the rollback journal.
+func swapMain(ctx context.Context, cfg config.Config, snapshotPath string) (string, error) {
+	logger := logging.FromContext(ctx)
 
-	// Remove old subvolume if it exists
-	if _, err := os.Stat(oldSubvolMainPath); err == nil {
-		if dryRun {
-			fmt.Printf("[dry-run] Would delete old subvolume: %s\n", oldSubvolMainPath)
-		} else {
-			fmt.Printf("Removing old subvolume: %s\n", oldSubvolMainPath)
-			err := exec.Command("btrfs", "subvolume", "delete", oldSubvolMainPath).Run()
-			if err != nil {
-				return fmt.Errorf("failed to delete old subvolume: %v", err)
-			}
-		}
-	}
+	subvolMainPath := filepath.Join(cfg.Mountpoint, cfg.SubvolMain)
+	oldSubvolMainPath := fmt.Sprintf("%s.old-%d", subvolMainPath, time.Now().Unix())
 
 	// Move the current subvolume
-	if dryRun {
-		fmt.Printf("[dry-run] Would move %s to %s\n", subvolMainPath, oldSubvolMainPath)
-	} else {
+	logger.Info("step", "step", "move", "from", subvolMainPath, "to", oldSubvolMainPath, "dry_run", dryRun)
+	if !dryRun {
 		err := exec.Command("mv", subvolMainPath, oldSubvolMainPath).Run()
 		if err != nil {
-			return fmt.Errorf("failed to move %s to %s: %v", subvolMainPath, oldSubvolMainPath, err)
+			return "", fmt.Errorf("failed to move %s to %s: %v", subvolMainPath, oldSubvolMainPath, err)
 		}
 	}
 
 	// Create the snapshot
-	if dryRun {
-		fmt.Printf("[dry-run] Would create snapshot from %s to %s\n", snapshotPath, subvolMainPath)
-	} else {
+	logger.Info("step", "step", "snapshot", "from", snapshotPath, "to", subvolMainPath, "dry_run", dryRun)
+	if !dryRun {
 		err := exec.Command("btrfs", "subvolume", "snapshot", snapshotPath, subvolMainPath).Run()
 		if err != nil {
-			return fmt.Errorf("failed to create snapshot %s: %v", snapshotID, err)
+			return "", fmt.Errorf("failed to create snapshot %s: %v", snapshotPath, err)
 		}
 	}
 
 	// Set default subvolume
-	if dryRun {
-		fmt.Printf("[dry-run] Would set default subvolume to: %s\n", subvolMainPath)
-	} else {
+	logger.Info("step", "step", "set-default", "subvolume", subvolMainPath, "dry_run", dryRun)
+	if !dryRun {
 		err := exec.Command("btrfs", "subvolume", "set-default", subvolMainPath).Run()
 		if err != nil {
-			return fmt.Errorf("failed to set default subvolume: %v", err)
+			return "", fmt.Errorf("failed to set default subvolume: %v", err)
+		}
+	}
+
+	return oldSubvolMainPath, nil
+}
+
+// Rollback is implemented in recursive.go, which enumerates cfg.SubvolMain,
+// every configured cfg.Subvolumes entry, and any subvolumes nested below
+// each one's snapshot.
+
+// Undo reverses the Nth most recent committed rollback recorded in the
+// journal (1 = latest): for every subvolume that rollback swapped, it
+// deletes the currently active subvolume and renames its preserved
+// ".old-<timestamp>" subvolume back into place, in reverse swap order.
+func Undo(ctx context.Context, cfg config.Config, n int) error {
+	logger := logging.FromContext(ctx)
+
+	st, err := store.Open(cfg.StorePath)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	record, err := st.Get(n)
+	if err != nil {
+		return withCode(CodeNothingToUndo, err)
+	}
+	if record.Status != store.StatusCommitted {
+		return withCode(CodeNothingToUndo, fmt.Errorf("rollback #%d was not committed (status: %s), nothing to undo", record.ID, record.Status))
+	}
+	if record.DryRun {
+		return withCode(CodeNothingToUndo, fmt.Errorf("rollback #%d was a dry run, nothing to undo", record.ID))
+	}
+	if len(record.Subvolumes) == 0 {
+		return withCode(CodeNothingToUndo, fmt.Errorf("rollback #%d has no preserved subvolumes to restore", record.ID))
+	}
+
+	for i := len(record.Subvolumes) - 1; i >= 0; i-- {
+		swap := record.Subvolumes[i]
+		isMain := swap.Name == cfg.SubvolMain
+
+		// swap.LivePath is the current default subvolume after a rollback
+		// of cfg.SubvolMain, and btrfs refuses to delete the default
+		// subvolume, so the default must be pointed elsewhere first and
+		// restored onto the undone subvolume afterwards.
+		if isMain {
+			logger.Info("step", "step", "set-default", "subvol_id", topLevelSubvolID, "dry_run", dryRun)
+		}
+		logger.Info("step", "step", "delete", "path", swap.LivePath, "dry_run", dryRun)
+		logger.Info("step", "step", "move", "from", swap.OldPath, "to", swap.LivePath, "dry_run", dryRun)
+		if dryRun {
+			continue
+		}
+
+		if isMain {
+			if err := setDefaultSubvol(ctx, cfg, topLevelSubvolID); err != nil {
+				return fmt.Errorf("failed to clear default subvolume before undo: %v", err)
+			}
+		}
+
+		if err := exec.Command("btrfs", "subvolume", "delete", swap.LivePath).Run(); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", swap.LivePath, err)
+		}
+
+		if err := exec.Command("mv", swap.OldPath, swap.LivePath).Run(); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %v", swap.OldPath, swap.LivePath, err)
+		}
+
+		if isMain {
+			restoredID, err := resolveSubvolID(cfg, swap.LivePath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve restored subvolume %s: %v", swap.LivePath, err)
+			}
+			if err := setDefaultSubvol(ctx, cfg, restoredID); err != nil {
+				return fmt.Errorf("failed to set default subvolume back to %s: %v", swap.LivePath, err)
+			}
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if err := st.MarkUndone(record); err != nil {
+		return err
+	}
+
+	logger.Info("undo complete", "rollback_id", record.ID, "subvolumes_restored", len(record.Subvolumes))
+	return nil
+}
+
+// subvolEntry is a single row parsed from `btrfs subvolume list -o`.
+type subvolEntry struct {
+	ID   string
+	Path string
+}
+
+// listSubvolumesByID parses `btrfs subvolume list -o <mountpoint>` and returns
+// every subvolume found below the mountpoint as an ID/path pair.
+func listSubvolumesByID(cfg config.Config) ([]subvolEntry, error) {
+	out, err := exec.Command("btrfs", "subvolume", "list", "-o", cfg.Mountpoint).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subvolumes of %s: %v", cfg.Mountpoint, err)
+	}
+
+	var entries []subvolEntry
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 {
+			continue
+		}
+		entries = append(entries, subvolEntry{ID: fields[1], Path: fields[len(fields)-1]})
+	}
+
+	return entries, nil
+}
+
+// resolveSubvolID returns the subvolume ID for target, a path underneath
+// cfg.Mountpoint, by matching it against `btrfs subvolume list -o`.
+func resolveSubvolID(cfg config.Config, target string) (string, error) {
+	relTarget, err := filepath.Rel(cfg.Mountpoint, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute relative path for %s: %v", target, err)
+	}
+
+	entries, err := listSubvolumesByID(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if entry.Path == relTarget {
+			return entry.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no subvolume found for path %s", target)
+}
+
+// resolveSubvolPath returns the path (relative to cfg.Mountpoint) of the
+// subvolume with the given ID, the inverse of resolveSubvolID.
+func resolveSubvolPath(cfg config.Config, subvolID string) (string, error) {
+	entries, err := listSubvolumesByID(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == subvolID {
+			return entry.Path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no subvolume found with id %s", subvolID)
+}
+
+// setDefaultSubvol sets subvolID as the default subvolume of cfg.Mountpoint,
+// i.e. the subvolume mounted at boot when no subvolid is given explicitly.
+func setDefaultSubvol(ctx context.Context, cfg config.Config, subvolID string) error {
+	cmd := exec.Command("btrfs", "subvolume", "set-default", subvolID, cfg.Mountpoint)
+	logging.FromContext(ctx).Info("step", "step", "set-default", "subvol_id", subvolID, "dry_run", dryRun)
+	if dryRun {
+		return nil
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to set default subvolume to %s: %v", subvolID, err)
+	}
+
+	return nil
+}
+
+// setGrubEnv sets a single key in the GRUB environment block at cfg.GrubEnvPath.
+func setGrubEnv(ctx context.Context, cfg config.Config, key, value string) error {
+	cmd := exec.Command("grub-editenv", cfg.GrubEnvPath, "set", fmt.Sprintf("%s=%s", key, value))
+	logging.FromContext(ctx).Info("step", "step", "grub-set", "key", key, "value", value, "dry_run", dryRun)
+	if dryRun {
+		return nil
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to set grub environment variable %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// clearGrubEnv unsets the given keys in the GRUB environment block at cfg.GrubEnvPath.
+func clearGrubEnv(ctx context.Context, cfg config.Config, keys ...string) error {
+	args := append([]string{cfg.GrubEnvPath, "unset"}, keys...)
+	cmd := exec.Command("grub-editenv", args...)
+	logging.FromContext(ctx).Info("step", "step", "grub-unset", "keys", keys, "dry_run", dryRun)
+	if dryRun {
+		return nil
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to unset grub environment variables %v: %v", keys, err)
+	}
+
+	return nil
+}
+
+// getGrubEnv reads a single key from the GRUB environment block at cfg.GrubEnvPath.
+func getGrubEnv(cfg config.Config, key string) (string, error) {
+	out, err := exec.Command("grub-editenv", cfg.GrubEnvPath, "list").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read grub environment: %v", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && parts[0] == key {
+			return parts[1], nil
 		}
 	}
 
-	fmt.Printf("Rollback completed using snapshot %s\n", snapshotID)
+	return "", fmt.Errorf("grub environment variable %s not set", key)
+}
+
+// BootInto configures a non-destructive "try before committing" boot: a
+// writable copy of the snapshot becomes the default subvolume for the next
+// boot, recorded via a GRUB environment variable, while cfg.SubvolMain is
+// left completely untouched. Use Confirm to promote it permanently, or
+// Revert to undo and boot back into the previous default.
+func BootInto(ctx context.Context, cfg config.Config, snapshotID string) error {
+	logger := logging.FromContext(ctx)
+
+	provider, err := GetProvider(cfg)
+	if err != nil {
+		return withCode(CodeProviderNotFound, err)
+	}
+
+	snapshotPath, err := provider.Resolve(cfg, snapshotID)
+	if err != nil {
+		return withCode(CodeSnapshotNotFound, err)
+	}
+
+	currentID, err := resolveSubvolID(cfg, filepath.Join(cfg.Mountpoint, cfg.SubvolMain))
+	if err != nil {
+		return withCode(CodeBootFailed, fmt.Errorf("failed to resolve current default subvolume: %v", err))
+	}
+
+	// provider.Resolve returns the snapshot's own read-only subvolume;
+	// booting it directly as root would come up read-only, so boot into a
+	// writable copy instead and leave the original snapshot untouched.
+	writablePath := filepath.Join(cfg.Mountpoint, fmt.Sprintf(".bootinto-%s", snapshotID))
+	if err := makeWritableCopy(ctx, snapshotPath, writablePath); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	if dryRun {
+		logger.Info("configured boot into snapshot", "snapshot_id", snapshotID, "subvol_main", cfg.SubvolMain, "dry_run", true)
+		return nil
+	}
+
+	subvolID, err := resolveSubvolID(cfg, writablePath)
+	if err != nil {
+		return withCode(CodeBootFailed, fmt.Errorf("failed to resolve subvolume id for %s: %v", writablePath, err))
+	}
+
+	if err := setDefaultSubvol(ctx, cfg, subvolID); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	if err := setGrubEnv(ctx, cfg, "active_snap", subvolID); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+	if err := setGrubEnv(ctx, cfg, "previous_snap", currentID); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	logger.Info("configured boot into snapshot", "snapshot_id", snapshotID, "subvol_id", subvolID, "subvol_main", cfg.SubvolMain)
+	return nil
+}
+
+// makeWritableCopy creates a writable snapshot of src at dst for BootInto,
+// first deleting any stale subvolume left over from a previous boot-into
+// attempt at the same path.
+func makeWritableCopy(ctx context.Context, src, dst string) error {
+	logger := logging.FromContext(ctx)
+
+	if realIsBtrfsSubvolume(dst) {
+		logger.Info("step", "step", "delete", "path", dst, "dry_run", dryRun)
+		if !dryRun {
+			if err := exec.Command("btrfs", "subvolume", "delete", dst).Run(); err != nil {
+				return fmt.Errorf("failed to delete stale %s: %v", dst, err)
+			}
+		}
+	}
+
+	logger.Info("step", "step", "snapshot", "from", src, "to", dst, "dry_run", dryRun)
+	if dryRun {
+		return nil
+	}
+
+	if err := exec.Command("btrfs", "subvolume", "snapshot", src, dst).Run(); err != nil {
+		return fmt.Errorf("failed to create writable snapshot from %s to %s: %v", src, dst, err)
+	}
+
+	return nil
+}
+
+// Confirm promotes the snapshot currently configured via BootInto to
+// cfg.SubvolMain, mirroring elemental's active/passive scheme: the booted
+// snapshot becomes the new SubvolMain and the previous one is preserved as a
+// timestamped "SubvolMain.old-<ts>", then the pending GRUB boot-into state is
+// cleared.
+func Confirm(ctx context.Context, cfg config.Config) error {
+	logger := logging.FromContext(ctx)
+
+	activeID, err := getGrubEnv(cfg, "active_snap")
+	if err != nil {
+		return withCode(CodeBootFailed, fmt.Errorf("no pending boot-into-snapshot to confirm: %v", err))
+	}
+
+	relSnapshotPath, err := resolveSubvolPath(cfg, activeID)
+	if err != nil {
+		return withCode(CodeSubvolumeNotFound, fmt.Errorf("failed to resolve booted subvolume %s: %v", activeID, err))
+	}
+
+	if _, err := swapMain(ctx, cfg, filepath.Join(cfg.Mountpoint, relSnapshotPath)); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	if err := clearGrubEnv(ctx, cfg, "active_snap", "previous_snap"); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	logger.Info("confirmed snapshot as new main subvolume", "subvol_id", activeID, "subvol_main", cfg.SubvolMain)
+	return nil
+}
+
+// Revert restores the default subvolume that was active before the most
+// recent BootInto call, undoing a pending boot-into-snapshot without
+// touching cfg.SubvolMain.
+func Revert(ctx context.Context, cfg config.Config) error {
+	logger := logging.FromContext(ctx)
+
+	previousID, err := getGrubEnv(cfg, "previous_snap")
+	if err != nil {
+		return withCode(CodeBootFailed, fmt.Errorf("no pending boot-into-snapshot to revert: %v", err))
+	}
+
+	if err := setDefaultSubvol(ctx, cfg, previousID); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	if err := clearGrubEnv(ctx, cfg, "active_snap", "previous_snap"); err != nil {
+		return withCode(CodeBootFailed, err)
+	}
+
+	logger.Info("reverted default subvolume", "subvol_id", previousID)
 	return nil
 }
@@ -0,0 +1,37 @@
+package btrfs
+
+// Code is a stable, machine-readable identifier attached to an error so
+// scripted callers (e.g. `--output json`) can branch on the failure reason
+// without parsing the human-readable message, which is free to change.
+type Code string
+
+const (
+	CodeMountFailed       Code = "mount_failed"
+	CodeProviderNotFound  Code = "provider_not_found"
+	CodeSnapshotNotFound  Code = "snapshot_not_found"
+	CodeSubvolumeNotFound Code = "subvolume_not_found"
+	CodeRollbackFailed    Code = "rollback_failed"
+	CodeNothingToUndo     Code = "nothing_to_undo"
+	CodeBootFailed        Code = "boot_failed"
+	CodeExportFailed      Code = "export_failed"
+	CodeImportFailed      Code = "import_failed"
+)
+
+// CodedError pairs an error with a stable Code. Unwrap returns the
+// underlying error, so callers can still use errors.Is/As against it.
+type CodedError struct {
+	Code Code
+	Err  error
+}
+
+func (e *CodedError) Error() string { return e.Err.Error() }
+func (e *CodedError) Unwrap() error { return e.Err }
+
+// withCode wraps err as a *CodedError tagged with code, or returns nil
+// unchanged so call sites can write `return withCode(Code..., err)`.
+func withCode(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
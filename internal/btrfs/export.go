@@ -0,0 +1,260 @@
+package btrfs
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/xeyossr/btrfs-rollback/internal/config"
+	"github.com/xeyossr/btrfs-rollback/internal/logging"
+)
+
+// ExportOptions controls how ExportSnapshot streams a snapshot.
+type ExportOptions struct {
+	// Parent, if set, is the ID of an earlier snapshot already present on
+	// the receiving side. btrfs send then produces an incremental stream
+	// containing only the differences since Parent.
+	Parent string
+
+	// Compression wraps the stream as it is written to w. One of "" (or
+	// "none"), "gzip", "zstd".
+	Compression string
+}
+
+// ExportSnapshot streams the snapshot identified by snapshotID through
+// `btrfs send` into w, honoring opts.Parent for an incremental stream and
+// opts.Compression for an optional compression wrapper. Honors dry-run mode
+// by printing the command instead of executing it.
+func ExportSnapshot(ctx context.Context, cfg config.Config, snapshotID string, w io.Writer, opts ExportOptions) error {
+	logger := logging.FromContext(ctx)
+
+	provider, err := GetProvider(cfg)
+	if err != nil {
+		return withCode(CodeProviderNotFound, err)
+	}
+
+	snapshotPath, err := provider.Resolve(cfg, snapshotID)
+	if err != nil {
+		return withCode(CodeSnapshotNotFound, err)
+	}
+
+	args := []string{"send"}
+	if opts.Parent != "" {
+		parentPath, err := provider.Resolve(cfg, opts.Parent)
+		if err != nil {
+			return withCode(CodeSnapshotNotFound, fmt.Errorf("failed to resolve parent snapshot %s: %v", opts.Parent, err))
+		}
+		args = append(args, "-p", parentPath)
+	}
+	args = append(args, snapshotPath)
+
+	cmd := exec.Command("btrfs", args...)
+	logger.Info("step", "step", "send", "snapshot_id", snapshotID, "parent", opts.Parent, "compression", opts.Compression, "dry_run", dryRun)
+	if dryRun {
+		return nil
+	}
+
+	dst, closeDst, err := wrapCompressionWriter(w, opts.Compression)
+	if err != nil {
+		return err
+	}
+
+	cmd.Stdout = dst
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		closeDst()
+		return withCode(CodeExportFailed, fmt.Errorf("failed to export snapshot %s: %v", snapshotID, err))
+	}
+
+	if err := closeDst(); err != nil {
+		return withCode(CodeExportFailed, fmt.Errorf("failed to flush exported stream: %v", err))
+	}
+
+	return nil
+}
+
+// ImportOptions controls how ImportSnapshot reads a stream.
+type ImportOptions struct {
+	// Compression must match the compression ExportSnapshot used to produce
+	// the stream: "" (or "none"), "gzip", "zstd".
+	Compression string
+}
+
+// ImportSnapshot reads a `btrfs send` stream from r via `btrfs receive`,
+// placing it under cfg.SubvolSnapshots/<newID>/snapshot with a synthesized
+// info.xml so it shows up alongside Snapper-managed snapshots in the
+// existing TUI. btrfs receive names the new subvolume after the one that was
+// sent, which for a plain-provider export isn't "snapshot", so it is renamed
+// into place afterwards. Returns the new snapshot's ID.
+func ImportSnapshot(ctx context.Context, cfg config.Config, r io.Reader, opts ImportOptions) (string, error) {
+	logger := logging.FromContext(ctx)
+
+	newID, err := nextSnapshotID(cfg)
+	if err != nil {
+		return "", withCode(CodeImportFailed, err)
+	}
+
+	destDir := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots, newID)
+
+	logger.Info("step", "step", "receive", "snapshot_id", newID, "dest", destDir, "compression", opts.Compression, "dry_run", dryRun)
+	if dryRun {
+		return newID, nil
+	}
+
+	src, err := wrapDecompressionReader(r, opts.Compression)
+	if err != nil {
+		return "", withCode(CodeImportFailed, err)
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", withCode(CodeImportFailed, fmt.Errorf("failed to create snapshot directory %s: %v", destDir, err))
+	}
+
+	cmd := exec.Command("btrfs", "receive", destDir)
+	cmd.Stdin = src
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", withCode(CodeImportFailed, fmt.Errorf("failed to receive snapshot into %s: %v", destDir, err))
+	}
+
+	if err := renameReceivedSubvolume(destDir); err != nil {
+		return "", withCode(CodeImportFailed, err)
+	}
+
+	if err := writeSyntheticInfo(destDir, newID); err != nil {
+		return "", withCode(CodeImportFailed, err)
+	}
+
+	logger.Info("import complete", "snapshot_id", newID)
+	return newID, nil
+}
+
+// renameReceivedSubvolume renames the single subvolume btrfs receive placed
+// under destDir to "snapshot", the layout ReadSnapshotInfo and the snapper
+// provider's Resolve expect. btrfs receive always names it after whatever
+// the sending side called it, which is only "snapshot" when the export came
+// from a Snapper-managed source.
+func renameReceivedSubvolume(destDir string) error {
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s after receive: %v", destDir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if entry.Name() == "snapshot" {
+			return nil
+		}
+		received := filepath.Join(destDir, entry.Name())
+		snapshotPath := filepath.Join(destDir, "snapshot")
+		if err := os.Rename(received, snapshotPath); err != nil {
+			return fmt.Errorf("failed to rename received subvolume %s to %s: %v", received, snapshotPath, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no subvolume found in %s after receive", destDir)
+}
+
+// wrapCompressionWriter wraps w with the requested compression scheme,
+// returning the writer to use and a close func that must be called to flush
+// and finalize the compressed stream (a no-op when compression is disabled).
+func wrapCompressionWriter(w io.Writer, compression string) (io.Writer, func() error, error) {
+	switch compression {
+	case "", "none":
+		return w, func() error { return nil }, nil
+	case "gzip":
+		gz := gzip.NewWriter(w)
+		return gz, gz.Close, nil
+	case "zstd":
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create zstd writer: %v", err)
+		}
+		return zw, zw.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// wrapDecompressionReader wraps r with the inverse of wrapCompressionWriter,
+// the decompression scheme matching the compression the stream was produced
+// with.
+func wrapDecompressionReader(r io.Reader, compression string) (io.Reader, error) {
+	switch compression {
+	case "", "none":
+		return r, nil
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+		}
+		return gz, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %v", err)
+		}
+		return zr, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// nextSnapshotID returns one more than the highest numeric snapshot ID under
+// cfg.SubvolSnapshots, continuing the same numbering scheme Snapper uses.
+func nextSnapshotID(cfg config.Config) (string, error) {
+	snapshotDir := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots)
+
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to list snapshot directory %s: %v", snapshotDir, err)
+	}
+
+	max := 0
+	for _, entry := range entries {
+		id, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if id > max {
+			max = id
+		}
+	}
+
+	return strconv.Itoa(max + 1), nil
+}
+
+// writeSyntheticInfo writes an info.xml for an imported snapshot, matching
+// the layout ReadSnapshotInfo expects from Snapper.
+func writeSyntheticInfo(destDir, id string) error {
+	info := Snapshot{
+		ID:             id,
+		Method:         "imported",
+		Date:           time.Now().Format("2006-01-02 15:04:05"),
+		DescriptionStr: "imported via btrfs receive",
+	}
+
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode synthesized info.xml: %v", err)
+	}
+
+	infoPath := filepath.Join(destDir, "info.xml")
+	if err := os.WriteFile(infoPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", infoPath, err)
+	}
+
+	return nil
+}
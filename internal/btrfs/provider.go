@@ -0,0 +1,61 @@
+package btrfs
+
+import (
+	"fmt"
+
+	"github.com/xeyossr/btrfs-rollback/internal/config"
+)
+
+// SnapshotProvider abstracts over differing snapshot-metadata layouts
+// (Snapper, Timeshift, or a plain directory of subvolumes) so the rest of
+// btrfs-rollback doesn't need to know which tool produced a given snapshot.
+type SnapshotProvider interface {
+	// Name identifies the provider; matches config.Config.Provider.
+	Name() string
+	// List returns every snapshot this provider can find.
+	List(cfg config.Config) ([]Snapshot, error)
+	// Resolve returns the subvolume path for the snapshot with the given ID.
+	Resolve(cfg config.Config, id string) (string, error)
+}
+
+// detector is optionally implemented by a SnapshotProvider to support
+// auto-detection when config.Config.Provider is unset.
+type detector interface {
+	detect(cfg config.Config) bool
+}
+
+// providers holds every registered SnapshotProvider, keyed by Name().
+var providers = map[string]SnapshotProvider{}
+
+// registerProvider makes p available to GetProvider under p.Name().
+func registerProvider(p SnapshotProvider) {
+	providers[p.Name()] = p
+}
+
+func init() {
+	registerProvider(snapperProvider{})
+	registerProvider(timeshiftProvider{})
+	registerProvider(plainProvider{})
+}
+
+// GetProvider returns the SnapshotProvider named by cfg.Provider. If
+// cfg.Provider is empty, it auto-detects one by probing well-known paths,
+// falling back to "plain" if nothing more specific is found.
+func GetProvider(cfg config.Config) (SnapshotProvider, error) {
+	if cfg.Provider != "" {
+		p, ok := providers[cfg.Provider]
+		if !ok {
+			return nil, fmt.Errorf("unknown snapshot provider %q", cfg.Provider)
+		}
+		return p, nil
+	}
+
+	for _, name := range []string{"snapper", "timeshift"} {
+		p := providers[name]
+		if d, ok := p.(detector); ok && d.detect(cfg) {
+			return p, nil
+		}
+	}
+
+	return providers["plain"], nil
+}
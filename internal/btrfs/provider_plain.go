@@ -0,0 +1,80 @@
+package btrfs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xeyossr/btrfs-rollback/internal/config"
+)
+
+// plainProvider implements SnapshotProvider for a directory of bare btrfs
+// subvolumes with no metadata file of their own: each subvolume's directory
+// name is its ID, and its Date comes from `btrfs subvolume show`'s otime.
+// It has no detect method and is always the fallback provider.
+type plainProvider struct{}
+
+func (plainProvider) Name() string { return "plain" }
+
+// List returns one Snapshot per subvolume found directly under
+// cfg.SubvolSnapshots, sorted by ID in descending order.
+func (plainProvider) List(cfg config.Config) ([]Snapshot, error) {
+	snapshotDir := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots)
+
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot directory %s: %v", snapshotDir, err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		path := filepath.Join(snapshotDir, entry.Name())
+		if !entry.IsDir() || !realIsBtrfsSubvolume(path) {
+			continue
+		}
+
+		date, err := subvolOtime(path)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			ID:     entry.Name(),
+			Method: "plain",
+			Date:   date,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID > snapshots[j].ID })
+	return snapshots, nil
+}
+
+// Resolve returns the subvolume path for id, a directory name directly under
+// cfg.SubvolSnapshots.
+func (plainProvider) Resolve(cfg config.Config, id string) (string, error) {
+	path := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots, id)
+	if !realIsBtrfsSubvolume(path) {
+		return "", fmt.Errorf("no subvolume found for id %s at %s", id, path)
+	}
+	return path, nil
+}
+
+// subvolOtime returns the "Creation time" field from `btrfs subvolume show`,
+// used as a snapshot's Date when no metadata file is available.
+func subvolOtime(path string) (string, error) {
+	out, err := exec.Command("btrfs", "subvolume", "show", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect subvolume %s: %v", path, err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "Creation time:") {
+			return strings.TrimSpace(strings.TrimPrefix(trimmed, "Creation time:")), nil
+		}
+	}
+
+	return "", fmt.Errorf("no creation time found for subvolume %s", path)
+}
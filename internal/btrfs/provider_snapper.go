@@ -0,0 +1,101 @@
+package btrfs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/xeyossr/btrfs-rollback/internal/config"
+)
+
+// snapperProvider implements SnapshotProvider for Snapper's
+// <subvol_snapshots>/<id>/{snapshot,info.xml} layout.
+type snapperProvider struct{}
+
+func (snapperProvider) Name() string { return "snapper" }
+
+func (snapperProvider) detect(cfg config.Config) bool {
+	_, err := os.Stat(filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots))
+	return err == nil
+}
+
+// listSubvolumes returns all snapshot subvolumes inside the snapshot
+// directory defined in cfg.
+func listSubvolumes(cfg config.Config) ([]string, error) {
+	var subvolumes []string
+	snapshotDir := filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots)
+
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		fullPath := filepath.Join(snapshotDir, entry.Name(), "snapshot")
+		if entry.IsDir() && realIsBtrfsSubvolume(fullPath) {
+			subvolumes = append(subvolumes, fullPath)
+		}
+	}
+
+	return subvolumes, nil
+}
+
+// ReadSnapshotInfo reads the info.xml file next to snapshotPath (in its parent
+// directory) and returns a Snapshot
+func ReadSnapshotInfo(cfg config.Config, snapshotPath string) (Snapshot, error) {
+	infoXMLPath := filepath.Join(filepath.Dir(snapshotPath), "info.xml")
+
+	xmlData, err := os.ReadFile(infoXMLPath)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("could not read XML file: %v", err)
+	}
+
+	var snapshot Snapshot
+	err = xml.Unmarshal(xmlData, &snapshot)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("could not unmarshal XML: %v", err)
+	}
+
+	return snapshot, nil
+}
+
+// List returns all snapshots found in the snapshot subvolume directory,
+// sorted by numeric ID in descending order.
+func (snapperProvider) List(cfg config.Config) ([]Snapshot, error) {
+	subvolPaths, err := listSubvolumes(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot subvolumes: %v", err)
+	}
+
+	var snapshots []Snapshot
+	for _, subvol := range subvolPaths {
+		snapshot, err := ReadSnapshotInfo(cfg, subvol)
+		if err != nil {
+			return nil, fmt.Errorf("could not read snapshot info for %s: %v", subvol, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		idI, errI := strconv.Atoi(snapshots[i].ID)
+		idJ, errJ := strconv.Atoi(snapshots[j].ID)
+		if errI != nil {
+			return false
+		}
+		if errJ != nil {
+			return true
+		}
+		return idI > idJ
+	})
+
+	return snapshots, nil
+}
+
+// Resolve returns the path of the "snapshot" subvolume Snapper
+// keeps alongside each snapshot's info.xml.
+func (snapperProvider) Resolve(cfg config.Config, id string) (string, error) {
+	return filepath.Join(cfg.Mountpoint, cfg.SubvolSnapshots, id, "snapshot"), nil
+}
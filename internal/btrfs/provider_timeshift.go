@@ -0,0 +1,85 @@
+package btrfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/xeyossr/btrfs-rollback/internal/config"
+)
+
+// timeshiftSnapshotDir is the well-known root Timeshift stores its btrfs
+// snapshots under.
+const timeshiftSnapshotDir = "/run/timeshift/backup/timeshift-btrfs/snapshots"
+
+// timeshiftInfo mirrors the fields of Timeshift's per-snapshot info.json
+// that btrfs-rollback cares about.
+type timeshiftInfo struct {
+	UUID     string `json:"sys-uuid"`
+	Tags     string `json:"tags"`
+	Comments string `json:"comments"`
+	Date     string `json:"created"`
+}
+
+// timeshiftProvider implements SnapshotProvider for Timeshift's
+// <date>/{info.json,localhost/@} layout.
+type timeshiftProvider struct{}
+
+func (timeshiftProvider) Name() string { return "timeshift" }
+
+func (timeshiftProvider) detect(cfg config.Config) bool {
+	_, err := os.Stat(timeshiftSnapshotDir)
+	return err == nil
+}
+
+// List parses info.json for every snapshot under timeshiftSnapshotDir,
+// sorted by date (directory name) in descending order.
+func (timeshiftProvider) List(cfg config.Config) ([]Snapshot, error) {
+	entries, err := os.ReadDir(timeshiftSnapshotDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list timeshift snapshots: %v", err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		infoPath := filepath.Join(timeshiftSnapshotDir, entry.Name(), "info.json")
+		data, err := os.ReadFile(infoPath)
+		if err != nil {
+			continue
+		}
+
+		var info timeshiftInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", infoPath, err)
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			ID:             entry.Name(),
+			Method:         "timeshift",
+			Date:           info.Date,
+			DescriptionStr: info.Comments,
+			Tags:           info.Tags,
+			Comments:       info.Comments,
+			UUID:           info.UUID,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID > snapshots[j].ID })
+	return snapshots, nil
+}
+
+// Resolve returns the path of the root subvolume inside a Timeshift
+// snapshot, i.e. <date>/localhost/<SubvolMain>.
+func (timeshiftProvider) Resolve(cfg config.Config, id string) (string, error) {
+	path := filepath.Join(timeshiftSnapshotDir, id, "localhost", cfg.SubvolMain)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("timeshift snapshot %s not found at %s: %v", id, path, err)
+	}
+	return path, nil
+}
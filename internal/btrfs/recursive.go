@@ -0,0 +1,194 @@
+package btrfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/xeyossr/btrfs-rollback/internal/config"
+	"github.com/xeyossr/btrfs-rollback/internal/logging"
+	"github.com/xeyossr/btrfs-rollback/internal/store"
+)
+
+// subvolumeSwap describes one subvolume a rollback must swap: its config
+// name, the path of its live (currently mounted) copy, and the snapshot
+// subvolume to replace it with.
+type subvolumeSwap struct {
+	Name         string
+	LivePath     string
+	SnapshotPath string
+}
+
+// expandSwap builds the swap entry for name/snapshotRoot. Subvolumes nested
+// below snapshotRoot (e.g. inside "@") are deliberately not discovered here:
+// the forward rollback already carries them along with their parent's mv,
+// so swapping them again as independent entries would both duplicate that
+// data and leave Undo unable to delete the parent afterwards, since btrfs
+// refuses to delete a subvolume that still contains nested subvolumes.
+// Rollback is therefore restricted to the sibling top-level subvolumes
+// named explicitly in cfg (cfg.SubvolMain and each cfg.Subvolumes entry).
+func expandSwap(cfg config.Config, name, snapshotRoot string) ([]subvolumeSwap, error) {
+	livePath := filepath.Join(cfg.Mountpoint, name)
+	return []subvolumeSwap{{Name: name, LivePath: livePath, SnapshotPath: snapshotRoot}}, nil
+}
+
+// buildRollbackPlan enumerates every subvolume a rollback to snapshotID must
+// swap: cfg.SubvolMain (via the configured SnapshotProvider) plus every
+// configured cfg.Subvolumes entry. The main subvolume's swap is always
+// first. See expandSwap for why subvolumes nested below these are not
+// discovered and swapped independently.
+func buildRollbackPlan(cfg config.Config, snapshotID string) ([]subvolumeSwap, error) {
+	provider, err := GetProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mainSnapshotPath, err := provider.Resolve(cfg, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := expandSwap(cfg, cfg.SubvolMain, mainSnapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sv := range cfg.Subvolumes {
+		snapshotPath := filepath.Join(cfg.Mountpoint, sv.Snapshots, snapshotID, "snapshot")
+		expanded, err := expandSwap(cfg, sv.Name, snapshotPath)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, expanded...)
+	}
+
+	return plan, nil
+}
+
+// swapSubvolume moves swap.LivePath out of the way into a uniquely named
+// "<LivePath>.old-<ts>" subvolume and replaces it with a fresh writable
+// snapshot taken from swap.SnapshotPath, returning the preserved old path.
+func swapSubvolume(logger *slog.Logger, swap subvolumeSwap, ts int64) (string, error) {
+	oldPath := fmt.Sprintf("%s.old-%d", swap.LivePath, ts)
+
+	logger.Info("step", "step", "move", "from", swap.LivePath, "to", oldPath, "dry_run", dryRun)
+	logger.Info("step", "step", "snapshot", "from", swap.SnapshotPath, "to", swap.LivePath, "dry_run", dryRun)
+
+	if dryRun {
+		return oldPath, nil
+	}
+
+	if err := exec.Command("mv", swap.LivePath, oldPath).Run(); err != nil {
+		return "", fmt.Errorf("failed to move %s to %s: %v", swap.LivePath, oldPath, err)
+	}
+
+	if err := exec.Command("btrfs", "subvolume", "snapshot", swap.SnapshotPath, swap.LivePath).Run(); err != nil {
+		if restoreErr := exec.Command("mv", oldPath, swap.LivePath).Run(); restoreErr != nil {
+			logger.Warn("failed to restore subvolume after a failed snapshot", "path", swap.LivePath, "error", restoreErr)
+		}
+		return "", fmt.Errorf("failed to create snapshot %s: %v", swap.SnapshotPath, err)
+	}
+
+	return oldPath, nil
+}
+
+// revertSubvolumeSwap undoes swapSubvolume: deletes the newly created
+// subvolume at swap.LivePath and moves oldPath back into its place. Used to
+// roll back a partially completed, failed Rollback.
+func revertSubvolumeSwap(logger *slog.Logger, swap subvolumeSwap, oldPath string) {
+	if err := exec.Command("btrfs", "subvolume", "delete", swap.LivePath).Run(); err != nil {
+		logger.Warn("failed to delete subvolume while reverting a failed rollback", "path", swap.LivePath, "error", err)
+		return
+	}
+	if err := exec.Command("mv", oldPath, swap.LivePath).Run(); err != nil {
+		logger.Warn("failed to restore subvolume while reverting a failed rollback", "path", swap.LivePath, "from", oldPath, "error", err)
+	}
+}
+
+// Rollback performs a transactional rollback to snapshotID: it swaps
+// cfg.SubvolMain and every configured cfg.Subvolumes entry, moving
+// each aside to a timestamped "<name>.old-<ts>" and replacing it with a
+// fresh writable snapshot, then makes cfg.SubvolMain the default subvolume.
+// If any single swap fails, every swap already performed is reverted before
+// returning the error, so a partial rollback is never left in place.
+// Dry-run only prints the full ordered plan and touches neither disk nor the
+// rollback journal, so it can never leave behind a fabricated "committed"
+// record for Undo to act on. A real run is recorded in the rollback journal
+// (internal/store) as "pending" before these steps run and "committed" once
+// they all succeed, so Undo can restore them later.
+func Rollback(ctx context.Context, cfg config.Config, snapshotID string) error {
+	logger := logging.FromContext(ctx)
+
+	plan, err := buildRollbackPlan(cfg, snapshotID)
+	if err != nil {
+		return withCode(CodeSnapshotNotFound, err)
+	}
+
+	if dryRun {
+		ts := time.Now().Unix()
+		logger.Info("rollback plan", "snapshot_id", snapshotID, "subvolumes", len(plan))
+		for _, swap := range plan {
+			logger.Info("planned step", "step", "move", "from", swap.LivePath, "to", fmt.Sprintf("%s.old-%d", swap.LivePath, ts))
+			logger.Info("planned step", "step", "snapshot", "from", swap.SnapshotPath, "to", swap.LivePath)
+		}
+		logger.Info("step", "step", "set-default", "subvolume", filepath.Join(cfg.Mountpoint, cfg.SubvolMain), "dry_run", true)
+		return nil
+	}
+
+	st, err := store.Open(cfg.StorePath)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	record, err := st.BeginRollback(snapshotID, false)
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now().Unix()
+	var done []store.SubvolumeSwap
+
+	fail := func(err error) error {
+		for i := len(done) - 1; i >= 0; i-- {
+			revertSubvolumeSwap(logger, subvolumeSwap{Name: done[i].Name, LivePath: done[i].LivePath}, done[i].OldPath)
+		}
+		if failErr := st.Fail(record); failErr != nil {
+			logger.Warn("failed to record rollback failure", "error", failErr)
+		}
+		return withCode(CodeRollbackFailed, err)
+	}
+
+	for _, swap := range plan {
+		oldPath, err := swapSubvolume(logger, swap, ts)
+		if err != nil {
+			return fail(err)
+		}
+		done = append(done, store.SubvolumeSwap{Name: swap.Name, LivePath: swap.LivePath, OldPath: oldPath})
+	}
+
+	subvolMainPath := filepath.Join(cfg.Mountpoint, cfg.SubvolMain)
+	logger.Info("step", "step", "set-default", "subvolume", subvolMainPath, "dry_run", dryRun)
+	if !dryRun {
+		if err := exec.Command("btrfs", "subvolume", "set-default", subvolMainPath).Run(); err != nil {
+			return fail(fmt.Errorf("failed to set default subvolume: %v", err))
+		}
+	}
+
+	newSubvolID, err := resolveSubvolID(cfg, subvolMainPath)
+	if err != nil {
+		// The swap itself already succeeded; a missing ID only weakens
+		// bookkeeping, so it isn't fatal to the rollback.
+		newSubvolID = ""
+	}
+
+	if err := st.Commit(record, done, newSubvolID); err != nil {
+		return err
+	}
+
+	logger.Info("rollback complete", "snapshot_id", snapshotID, "subvolumes_swapped", len(done))
+	return nil
+}
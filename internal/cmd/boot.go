@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+)
+
+// bootCmd configures a non-destructive "try before committing" boot into a
+// snapshot: the snapshot becomes the default subvolume for the next boot
+// while the current main subvolume is left untouched.
+var bootCmd = &cobra.Command{
+	Use:   "boot <snapshot-id>",
+	Short: "Boot into a snapshot without touching the current root subvolume",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		return btrfs.BootInto(command.Context(), cfg, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(bootCmd)
+}
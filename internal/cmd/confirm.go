@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+)
+
+// confirmCmd promotes the snapshot currently booted via `boot` to the main
+// subvolume, mirroring elemental's active/passive scheme.
+var confirmCmd = &cobra.Command{
+	Use:   "confirm",
+	Short: "Promote the currently booted snapshot to the main subvolume",
+	Args:  cobra.NoArgs,
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		return btrfs.Confirm(command.Context(), cfg)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(confirmCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+)
+
+var (
+	exportFile        string
+	exportParent      string
+	exportCompression string
+)
+
+// exportCmd streams a snapshot as a `btrfs send` stream for off-host backup.
+var exportCmd = &cobra.Command{
+	Use:   "export <snapshot-id>",
+	Short: "Export a snapshot as a btrfs send stream",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		var w io.Writer = os.Stdout
+		if exportFile != "" && !DryRun() {
+			f, err := os.Create(exportFile)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %v", exportFile, err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		return btrfs.ExportSnapshot(command.Context(), cfg, args[0], w, btrfs.ExportOptions{
+			Parent:      exportParent,
+			Compression: exportCompression,
+		})
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFile, "file", "o", "", "Write the stream to this file instead of stdout")
+	exportCmd.Flags().StringVarP(&exportParent, "parent", "p", "", "Parent snapshot ID for an incremental stream")
+	exportCmd.Flags().StringVar(&exportCompression, "compression", "", "Compress the stream: gzip, zstd, or none")
+
+	RootCmd.AddCommand(exportCmd)
+}
@@ -10,12 +10,14 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/xeyossr/btrfs-rollback/internal/config"
+	"github.com/xeyossr/btrfs-rollback/internal/logging"
 )
 
 var (
 	cfg     config.Config
 	cfgPath string
 	dryRun  bool
+	output  string
 
 	// RootCmd is the main entry point for the CLI
 	RootCmd = &cobra.Command{
@@ -30,6 +32,15 @@ func init() {
 	// Setup persistent flags
 	RootCmd.PersistentFlags().StringVarP(&cfgPath, "config", "c", "/etc/btrfs-rollback.toml", "Path to config file")
 	RootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Simulate the operations without making changes")
+	RootCmd.PersistentFlags().StringVar(&output, "output", "text", "Output format: text or json")
+
+	RootCmd.PersistentPreRunE = func(command *cobra.Command, args []string) error {
+		if output != "text" && output != "json" {
+			return fmt.Errorf("invalid --output %q: must be \"text\" or \"json\"", output)
+		}
+		command.SetContext(logging.WithLogger(command.Context(), logging.New(output)))
+		return nil
+	}
 }
 
 // loadConfig reads the config file from the given path or falls back to default
@@ -56,3 +67,8 @@ func Config() config.Config {
 func DryRun() bool {
 	return dryRun
 }
+
+// Output returns the requested --output format, "text" or "json".
+func Output() string {
+	return output
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xeyossr/btrfs-rollback/internal/store"
+	"github.com/xeyossr/btrfs-rollback/internal/ui"
+)
+
+// historyCmd lists past rollbacks recorded in the rollback journal.
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past rollbacks recorded in the rollback journal",
+	Args:  cobra.NoArgs,
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+
+		st, err := store.Open(cfg.StorePath)
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		records, err := st.List()
+		if err != nil {
+			return err
+		}
+
+		return ui.ShowHistory(records)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+)
+
+var importCompression string
+
+// importCmd receives a `btrfs send` stream and places it as a new snapshot.
+var importCmd = &cobra.Command{
+	Use:   "import <file.btrfs>",
+	Short: "Import a btrfs send stream as a new snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", args[0], err)
+		}
+		defer f.Close()
+
+		_, err = btrfs.ImportSnapshot(command.Context(), cfg, f, btrfs.ImportOptions{
+			Compression: importCompression,
+		})
+		return err
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importCompression, "compression", "", "Decompression to apply to the stream before `btrfs receive`: gzip, zstd, or none; must match what export used")
+
+	RootCmd.AddCommand(importCmd)
+}
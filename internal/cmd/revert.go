@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+)
+
+// revertCmd restores the default subvolume that was active before the last
+// `boot` call, undoing a pending boot-into-snapshot.
+var revertCmd = &cobra.Command{
+	Use:   "revert",
+	Short: "Undo a pending boot-into-snapshot and restore the previous default",
+	Args:  cobra.NoArgs,
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		return btrfs.Revert(command.Context(), cfg)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(revertCmd)
+}
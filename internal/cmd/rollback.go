@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+	"github.com/xeyossr/btrfs-rollback/internal/ui"
+)
+
+var (
+	rollbackID  string
+	rollbackYes bool
+)
+
+// rollbackCmd performs the same destructive rollback as RootCmd's default
+// behavior, but scriptably: --id picks the snapshot without the interactive
+// TUI and --yes skips the confirmation prompt, so it can be driven from
+// Ansible, a systemd unit, or any other non-interactive caller, e.g.
+// `btrfs-rollback rollback --id 42 --yes --output json`. Without --id, it
+// prints the available snapshots as JSON and exits when --output json is
+// set; otherwise it lets the user pick a snapshot in the TUI.
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back to a snapshot non-interactively",
+	Args:  cobra.NoArgs,
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+		ctx := command.Context()
+
+		utils.CheckIfRoot()
+
+		if err := btrfs.MountSubvol(ctx, cfg); err != nil {
+			return err
+		}
+
+		snapshotID := rollbackID
+		if snapshotID == "" {
+			snapshots, err := btrfs.GetSnapshots(cfg)
+			if err != nil {
+				return err
+			}
+
+			if Output() == "json" {
+				return json.NewEncoder(os.Stdout).Encode(snapshots)
+			}
+
+			picked, err := ui.RunUI(snapshots)
+			if err != nil {
+				return err
+			}
+			ui.ClearScreen()
+			snapshotID = picked
+		}
+
+		if !rollbackYes && !DryRun() {
+			confirmed := ui.Confirm("Are you sure you want to rollback to snapshot " + snapshotID + "?")
+			fmt.Println()
+			if !confirmed {
+				return nil
+			}
+		}
+
+		return btrfs.Rollback(ctx, cfg, snapshotID)
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().StringVar(&rollbackID, "id", "", "Snapshot ID to roll back to; without it, snapshots are listed as JSON with --output json, or picked in the TUI otherwise")
+	rollbackCmd.Flags().BoolVar(&rollbackYes, "yes", false, "Don't prompt for confirmation")
+
+	RootCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+	"github.com/xeyossr/btrfs-rollback/internal/ui"
+)
+
+// init wires up RootCmd's default behavior: mount the BTRFS subvolume, let
+// the user pick a snapshot from the TUI, confirm, and perform a destructive
+// rollback. Use the boot/confirm/revert subcommands for the non-destructive
+// "try before committing" flow instead.
+func init() {
+	RootCmd.RunE = func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		ctx := command.Context()
+
+		if err := btrfs.MountSubvol(ctx, cfg); err != nil {
+			return err
+		}
+
+		snapshots, err := btrfs.GetSnapshots(cfg)
+		if err != nil {
+			return err
+		}
+
+		snapshotID, err := ui.RunUI(snapshots)
+		if err != nil {
+			return err
+		}
+
+		ui.ClearScreen()
+
+		if !DryRun() {
+			confirmed := ui.Confirm("Are you sure you want to rollback to snapshot " + snapshotID + "?")
+			fmt.Println()
+			if !confirmed {
+				return nil
+			}
+		}
+
+		return btrfs.Rollback(ctx, cfg, snapshotID)
+	}
+}
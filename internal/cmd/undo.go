@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	utils "github.com/xeyossr/btrfs-rollback/internal"
+	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
+)
+
+// undoCmd reverses a committed rollback, restoring the ".old-<timestamp>"
+// subvolume it preserved. N defaults to 1 (the most recent rollback).
+var undoCmd = &cobra.Command{
+	Use:   "undo [N]",
+	Short: "Undo a past rollback, restoring its preserved subvolume",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(command *cobra.Command, args []string) error {
+		cfg := Config()
+		btrfs.SetDryRun(DryRun())
+
+		utils.CheckIfRoot()
+
+		n := 1
+		if len(args) == 1 {
+			parsed, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid history index %q: %v", args[0], err)
+			}
+			n = parsed
+		}
+
+		return btrfs.Undo(command.Context(), cfg, n)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(undoCmd)
+}
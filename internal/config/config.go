@@ -23,6 +23,32 @@ type Config struct {
 	SubvolSnapshots string  `toml:"subvol_snapshots"`
 	Mountpoint      string  `toml:"mountpoint"`
 	Dev             *string `toml:"dev"`
+
+	// GrubEnvPath is the path to the GRUB environment block used by the
+	// boot/confirm/revert commands to record which snapshot the next boot
+	// should load. Only read when those commands are used.
+	GrubEnvPath string `toml:"grub_env_path"`
+
+	// StorePath is where the rollback journal (see internal/store) is kept.
+	StorePath string `toml:"store_path"`
+
+	// Provider selects the snapshot-metadata layout to read snapshots from:
+	// "snapper", "timeshift", or "plain". Left empty, it is auto-detected by
+	// probing well-known paths (see btrfs.GetProvider).
+	Provider string `toml:"provider"`
+
+	// Subvolumes lists sibling subvolumes (e.g. "@home", "@var") that should
+	// be rolled back alongside SubvolMain. Each entry's own Snapshots
+	// directory follows the same "<id>/snapshot" Snapper layout as
+	// SubvolSnapshots.
+	Subvolumes []SubvolumeMapping `toml:"subvolumes"`
+}
+
+// SubvolumeMapping pairs a sibling subvolume with the directory Snapper
+// keeps its snapshots in, for recursive rollback.
+type SubvolumeMapping struct {
+	Name      string `toml:"name"`
+	Snapshots string `toml:"snapshots"`
 }
 
 // DefaultConfig holds the default values used by the btrfs-rollback tool
@@ -31,6 +57,9 @@ var DefaultConfig = Config{
 	SubvolMain:      "@",
 	SubvolSnapshots: "@snapshots",
 	Mountpoint:      "/btrfs",
+	GrubEnvPath:     "/boot/grub/grubenv",
+	StorePath:       "/var/lib/btrfs-rollback/state.db",
+	Provider:        "snapper",
 }
 
 // ParseConfig reads the configuration file located at configPath,
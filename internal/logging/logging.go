@@ -0,0 +1,42 @@
+// Package logging provides the slog.Logger used throughout btrfs-rollback.
+// The CLI's --output flag selects between a human-readable text format and
+// a machine-readable JSON format; the chosen logger is attached to a
+// context.Context by the cmd package so internal/btrfs and internal/cmd can
+// log without depending on each other.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+type contextKey struct{}
+
+// New returns a logger writing to stderr. format selects the handler:
+// "json" for slog's JSON handler, anything else (including "" and "text")
+// for its plain text handler.
+func New(format string) *slog.Logger {
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	}
+	return slog.New(handler)
+}
+
+// WithLogger returns a copy of ctx carrying logger, retrievable with FromContext.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx. If ctx carries none (e.g. in
+// tests that build a config.Config directly), it returns a default text
+// logger so callers never have to nil-check.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return New("text")
+}
@@ -0,0 +1,197 @@
+// Package store persists a journal of rollback transactions in a bbolt
+// database so that a crash midway through a rollback leaves a recoverable
+// record, past rollbacks can be listed, and committed ones can be undone.
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultPath is where the rollback journal is stored when config.Config
+// does not specify one.
+const DefaultPath = "/var/lib/btrfs-rollback/state.db"
+
+// bucketName holds every rollback record, keyed by its big-endian ID.
+const bucketName = "rollbacks"
+
+// Status describes the outcome of a rollback transaction.
+type Status string
+
+const (
+	// StatusPending marks a record written before the mv/snapshot/set-default
+	// steps have been attempted.
+	StatusPending Status = "pending"
+	// StatusCommitted marks a rollback whose steps all succeeded.
+	StatusCommitted Status = "committed"
+	// StatusFailed marks a rollback that did not complete; the swaps it had
+	// already made are reverted, moving each preserved ".old" subvolume back.
+	StatusFailed Status = "failed"
+	// StatusUndone marks a committed rollback that was later reversed via undo.
+	StatusUndone Status = "undone"
+)
+
+// SubvolumeSwap records one subvolume swapped by a rollback: its config
+// name (e.g. "@", "@home"), its live mount path, and the timestamped ".old"
+// path it was preserved under.
+type SubvolumeSwap struct {
+	Name     string `json:"name"`
+	LivePath string `json:"live_path"`
+	OldPath  string `json:"old_path"`
+}
+
+// Record is one entry in the rollback journal.
+type Record struct {
+	ID          uint64          `json:"id"`
+	Timestamp   time.Time       `json:"timestamp"`
+	SnapshotID  string          `json:"snapshot_id"`
+	Subvolumes  []SubvolumeSwap `json:"subvolumes"`
+	NewSubvolID string          `json:"new_subvol_id"`
+	DryRun      bool            `json:"dry_run"`
+	Status      Status          `json:"status"`
+}
+
+// Store wraps a bbolt database holding the rollback journal.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (creating if necessary) the bbolt database at path. An empty
+// path falls back to DefaultPath.
+func Open(path string) (*Store, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rollback journal at %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize rollback journal: %v", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// BeginRollback writes a "pending" record for a rollback about to start and
+// returns it so the caller can mark it committed or failed afterwards.
+func (s *Store) BeginRollback(snapshotID string, dryRun bool) (*Record, error) {
+	record := &Record{
+		Timestamp:  time.Now(),
+		SnapshotID: snapshotID,
+		DryRun:     dryRun,
+		Status:     StatusPending,
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(idKey(id), data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record pending rollback: %v", err)
+	}
+
+	return record, nil
+}
+
+// Commit marks record as committed, recording every subvolume swapped and
+// the resulting default subvolume ID.
+func (s *Store) Commit(record *Record, subvolumes []SubvolumeSwap, newSubvolID string) error {
+	record.Status = StatusCommitted
+	record.Subvolumes = subvolumes
+	record.NewSubvolID = newSubvolID
+	return s.put(record)
+}
+
+// Fail marks record as failed.
+func (s *Store) Fail(record *Record) error {
+	record.Status = StatusFailed
+	return s.put(record)
+}
+
+// MarkUndone marks a previously committed record as undone.
+func (s *Store) MarkUndone(record *Record) error {
+	record.Status = StatusUndone
+	return s.put(record)
+}
+
+func (s *Store) put(record *Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(bucketName)).Put(idKey(record.ID), data)
+	})
+}
+
+// List returns every rollback record, most recent first.
+func (s *Store) List() ([]Record, error) {
+	var records []Record
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).ForEach(func(_, v []byte) error {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollback journal: %v", err)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].ID > records[j].ID })
+	return records, nil
+}
+
+// Get returns the Nth most recent record (1 = latest).
+func (s *Store) Get(n int) (*Record, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(records) {
+		return nil, fmt.Errorf("no rollback history entry #%d", n)
+	}
+	return &records[n-1], nil
+}
+
+// idKey encodes a record ID as a big-endian byte slice suitable for use as a
+// bbolt key, keeping iteration order numeric rather than lexicographic.
+func idKey(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xeyossr/btrfs-rollback/internal/store"
+)
+
+// historyItem wraps a store.Record to make it compatible with the list UI.
+type historyItem struct {
+	store.Record
+}
+
+// Title shows the journal entry number and the snapshot it rolled back to.
+func (h historyItem) Title() string {
+	return fmt.Sprintf("#%d snapshot %s", h.ID, h.SnapshotID)
+}
+
+// FilterValue returns the snapshot ID, used for searching/filtering in the list.
+func (h historyItem) FilterValue() string { return h.SnapshotID }
+
+// Description combines the status and timestamp for displaying in the list.
+func (h historyItem) Description() string {
+	return fmt.Sprintf("%s | %s", h.Status, h.Timestamp.Format("2006-01-02 15:04:05"))
+}
+
+// historyModel is a read-only variant of model for browsing rollback history.
+type historyModel struct {
+	list list.Model
+}
+
+// Init initializes the program and prepares it for the TUI
+func (m historyModel) Init() tea.Cmd {
+	return tea.EnterAltScreen
+}
+
+// Update handles events such as window resizing and quitting
+func (m *historyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width-4, msg.Height-4)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "enter", "ctrl+c", "esc", "q":
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+// View renders the current view of the rollback history list
+func (m *historyModel) View() string {
+	return appStyle.Render(m.list.View())
+}
+
+// ShowHistory renders the rollback journal in the same list UI used for
+// snapshot selection. It is read-only: enter, esc, q or ctrl+c exits the
+// view, and other keys navigate the list.
+func ShowHistory(records []store.Record) error {
+	items := make([]list.Item, len(records))
+	for i, r := range records {
+		items[i] = historyItem{Record: r}
+	}
+
+	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
+	l.Title = "Rollback History"
+	l.Styles.Title = titleStyle
+
+	_, err := tea.NewProgram(&historyModel{list: l}, tea.WithAltScreen()).Run()
+	return err
+}
@@ -1,49 +1,38 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"os"
 
-	utils "github.com/xeyossr/btrfs-rollback/internal"
 	"github.com/xeyossr/btrfs-rollback/internal/btrfs"
 	"github.com/xeyossr/btrfs-rollback/internal/cmd"
-	"github.com/xeyossr/btrfs-rollback/internal/ui"
-)
-
-var (
-	Must = utils.Must
 )
 
 func main() {
 	if err := cmd.RootCmd.Execute(); err != nil {
-		log.Fatal(err)
+		if cmd.Output() == "json" {
+			reportJSONError(err)
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
 	}
+}
 
-	cfg := cmd.Config()
-	btrfs.SetDryRun(cmd.DryRun())
-
-	// Root?
-	utils.CheckIfRoot()
-	
-	// Mount the BTRFS subvolume first
-	err := btrfs.MountSubvol(cfg)
-	Must(err)
-
-	snapshots, err := btrfs.GetSnapshots(cfg)
-	Must(err)
-
-	snapshotID, err := ui.RunUI(snapshots)
-	Must(err)
-
-	ui.ClearScreen()
-
-	if !cmd.DryRun() {
-		confirmed := ui.Confirm("Are you sure you want to rollback to snapshot " + snapshotID + "?")
-		fmt.Println()
-		if !confirmed {
-			return
-		}
+// reportJSONError prints err to stderr as a JSON object, including its
+// machine-readable Code when it (or something it wraps) is a *btrfs.CodedError.
+func reportJSONError(err error) {
+	out := struct {
+		Error string `json:"error"`
+		Code  string `json:"code,omitempty"`
+	}{Error: err.Error()}
+
+	var coded *btrfs.CodedError
+	if errors.As(err, &coded) {
+		out.Code = string(coded.Code)
 	}
 
-	btrfs.Rollback(cfg, snapshotID)
+	json.NewEncoder(os.Stderr).Encode(out)
 }